@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	goToken "go/token"
+)
+
+// astToken wraps a single literal/identifier item once parse has decided it
+// isn't the start of a nested list: Type says which BasicLit/Ident kind it
+// lowers to, Pos is where it came from in the source so generateAST can
+// carry real positions into the synthesized AST instead of NoPos.
+type astToken struct {
+	Value string
+	Type  string
+	Pos   goToken.Pos
+}
+
+// generateAST lowers a parsed gisp program (forms is a []Any of astTokens,
+// nested []Any lists and commented wrappers, exactly as parse builds them)
+// into a *go/ast.File that printer.Fprint can render. Each top-level form
+// becomes its own `var _ = ...` declaration; there's no notion of gisp
+// (defn ...) producing a real Go func yet, so every form round-trips as an
+// expression statement in disguise. Comments that preceded a top-level
+// form land in file.Comments (or, for the `;;; `-doc-comment convention on
+// a `(def ...)`/`(defn ...)` form, on that declaration's Doc field).
+func generateAST(forms []Any) *ast.File {
+	file := &ast.File{
+		Name: ast.NewIdent("main"),
+	}
+	for _, f := range forms {
+		decl, groups := toDeclWithComments(f)
+		if decl != nil {
+			file.Decls = append(file.Decls, decl)
+		}
+		file.Comments = append(file.Comments, groups...)
+	}
+	addTimeImportIfNeeded(file)
+	return file
+}
+
+// toDeclWithComments lowers one top-level form to a declaration, pulling
+// any attached comments (see commented in comment.go) out to the side: a
+// trailing `;;; `-prefixed comment immediately before a (def ...)/(defn
+// ...) form becomes that GenDecl's Doc, and every other comment is
+// returned for the caller to add to file.Comments directly. A Form-less
+// commented (comments trailing at EOF with nothing to attach to) yields a
+// nil decl.
+func toDeclWithComments(f Any) (ast.Decl, []*ast.CommentGroup) {
+	c, ok := f.(commented)
+	if !ok {
+		return toDecl(f), nil
+	}
+	if c.Form == nil {
+		return nil, commentGroups(c.Comments)
+	}
+	decl := toDecl(c.Form)
+	groups := commentGroups(c.Comments)
+	if gd, ok := decl.(*ast.GenDecl); ok && isDefForm(c.Form) {
+		if last := c.Comments[len(c.Comments)-1]; last.isDoc() {
+			gd.Doc = groups[len(groups)-1]
+			groups = groups[:len(groups)-1]
+		}
+	}
+	return decl, groups
+}
+
+// toDecl wraps a top-level form as a blank-identifier var decl, the
+// simplest Go declaration that can hold an arbitrary expression. TokPos is
+// set to the form's own position (not NoPos) so go/printer, which places
+// file.Comments by comparing their Slash against surrounding decls' Pos,
+// can tell which declaration a comment belongs before rather than always
+// sorting it ahead of the first one.
+func toDecl(v Any) ast.Decl {
+	return &ast.GenDecl{
+		TokPos: formPos(v),
+		Tok:    goToken.VAR,
+		Specs: []ast.Spec{
+			&ast.ValueSpec{
+				Names:  []*ast.Ident{ast.NewIdent("_")},
+				Values: []ast.Expr{toExpr(v)},
+			},
+		},
+	}
+}
+
+// formPos finds the position of v's leading token: an astToken's own Pos,
+// the position of a list's first element (recursively, since that first
+// element may itself be a nested list), or NoPos for anything else (an
+// empty list, or a Symbol produced internally by parse/quote.go that never
+// carries a source position of its own).
+func formPos(v Any) goToken.Pos {
+	switch t := v.(type) {
+	case astToken:
+		return t.Pos
+	case commented:
+		return formPos(t.Form)
+	case []Any:
+		if len(t) == 0 {
+			return goToken.NoPos
+		}
+		return formPos(t[0])
+	default:
+		return goToken.NoPos
+	}
+}
+
+// toExpr lowers a single form to a Go expression: an astToken becomes a
+// BasicLit or Ident per its Type, a `(quote x)`/`(quasiquote x)` form (see
+// quote.go) expands to its Go equivalent, a commented wrapper is unwrapped
+// (comments only attach to top-level decls; see toDeclWithComments), and
+// any other nested []Any list `(f a b ...)` becomes a call expression
+// `f(a, b, ...)`.
+func toExpr(v Any) ast.Expr {
+	switch t := v.(type) {
+	case astToken:
+		return tokenExpr(t)
+	case commented:
+		return toExpr(t.Form)
+	case []Any:
+		if entries, ok := hashMapForm(t); ok {
+			return hashMapExpr(entries)
+		}
+		if sym, form, ok := quoteForm(t); ok {
+			switch sym {
+			case "quote":
+				return quoteExpr(form)
+			case "quasiquote":
+				return quasiquoteExpr(form)
+			case "unquote", "unquote-splice":
+				panic(&MacroError{Msg: fmt.Sprintf("%s used outside quasiquote", sym)})
+			}
+		}
+		if len(t) == 0 {
+			return ast.NewIdent("nil")
+		}
+		args := make([]ast.Expr, len(t)-1)
+		for i, a := range t[1:] {
+			args[i] = toExpr(a)
+		}
+		return &ast.CallExpr{Fun: toExpr(t[0]), Args: args}
+	default:
+		return ast.NewIdent("nil")
+	}
+}
+
+// quoteForm reports whether list is a reader-macro wrapper `[]Any{Symbol(sym), form}`
+// as produced by parse's quote-family handling, e.g. []Any{Symbol("quote"), x}.
+func quoteForm(list []Any) (sym Symbol, form Any, ok bool) {
+	if len(list) != 2 {
+		return "", nil, false
+	}
+	sym, ok = list[0].(Symbol)
+	if !ok {
+		return "", nil, false
+	}
+	return sym, list[1], true
+}
+
+// tokenExpr lowers one astToken to the Go expression it denotes: IDENT
+// becomes an Ident (a symbol reference or, as Fun in a CallExpr, a function
+// name), BOOL/CHAR/DURATION lower through their own rules in literal.go
+// since none of them round-trip as a plain BasicLit, and everything else
+// becomes a BasicLit of the matching kind.
+func tokenExpr(t astToken) ast.Expr {
+	switch t.Type {
+	case "IDENT":
+		id := ast.NewIdent(t.Value)
+		id.NamePos = t.Pos
+		return id
+	case "BOOL":
+		return boolExpr(t)
+	case "CHAR":
+		return charExpr(t)
+	case "DURATION":
+		return durationExpr(t)
+	default:
+		return &ast.BasicLit{ValuePos: t.Pos, Kind: litKind(t.Type), Value: t.Value}
+	}
+}
+
+// litKind maps an astToken.Type to the go/token.Token a BasicLit of that
+// kind is tagged with.
+func litKind(typ string) goToken.Token {
+	switch typ {
+	case "INT":
+		return goToken.INT
+	case "FLOAT":
+		return goToken.FLOAT
+	case "STRING":
+		return goToken.STRING
+	default:
+		return goToken.STRING
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"go/ast"
+	goToken "go/token"
+	"strings"
+)
+
+// comment is one `;`-led line comment captured by the lexer, still holding
+// its raw source text (semicolons and all) until generateAST turns it into
+// a real *ast.CommentGroup.
+type comment struct {
+	Text string
+	Pos  goToken.Pos
+}
+
+// newComment builds a comment from the itemComment item the lexer emitted.
+func newComment(t item) comment {
+	return comment{Text: t.val, Pos: t.pos}
+}
+
+// isDoc reports whether a comment uses the `;;; ` doc-comment convention
+// that binds it to the (def ...)/(defn ...) form immediately following it.
+func (c comment) isDoc() bool {
+	return strings.HasPrefix(c.Text, ";;;")
+}
+
+// commented wraps a parsed form together with the comment(s) that
+// immediately preceded it in the source. This is deliberately a
+// top-level-only feature: toExpr (ast.go) unwraps and ignores the
+// comments for any form nested inside a larger expression, since Go has
+// nowhere to hang a comment off an arbitrary sub-expression the way
+// gisp's forms can carry one, and only generateAST's top-level loop
+// (toDeclWithComments) turns them into real *ast.CommentGroups. A
+// comment that precedes or trails a nested form - inside a list, a map,
+// or a quote-family form - is dropped for the same reason, rather than
+// attached somewhere that would misplace it relative to the code it
+// commented. A Form of nil marks comments trailing at the end of input
+// with no form to attach to at all.
+type commented struct {
+	Comments []comment
+	Form     Any
+}
+
+// attachComments wraps form with any comments parse has accumulated since
+// the last form, if there are any; otherwise it returns form unchanged.
+func attachComments(l *lexer, form Any) Any {
+	return wrapComments(l.takePendingComments(), form)
+}
+
+// takePendingComments hands back whatever comments parse has accumulated
+// since the last form and clears the lexer's pending list. A form that
+// opens a nested list (parse's itemLeftParen/itemLeftBrace cases) must
+// take its pending comments before recursing into the list body: the
+// body's own itemRightParen/itemRightBrace handling clears
+// l.pendingComments too, and that clear would otherwise wipe out the
+// comments meant for the list itself rather than anything trailing inside
+// it.
+func (l *lexer) takePendingComments() []comment {
+	c := l.pendingComments
+	l.pendingComments = nil
+	return c
+}
+
+// wrapComments wraps form in a commented if there are any comments to
+// attach, or returns form unchanged otherwise.
+func wrapComments(comments []comment, form Any) Any {
+	if len(comments) == 0 {
+		return form
+	}
+	return commented{Comments: comments, Form: form}
+}
+
+// flushTrailingComments appends any comments still pending at EOF as a
+// Form-less commented marker, so a comment at the very end of a file isn't
+// silently dropped.
+func flushTrailingComments(l *lexer, p []Any) []Any {
+	if len(l.pendingComments) == 0 {
+		return p
+	}
+	c := l.pendingComments
+	l.pendingComments = nil
+	return append(p, commented{Comments: c})
+}
+
+// normalizeCommentText turns a raw gisp comment ("; foo" or ";;; foo")
+// into the Go `// foo` a *ast.Comment expects.
+func normalizeCommentText(raw string) string {
+	s := strings.TrimLeft(raw, ";")
+	s = strings.TrimPrefix(s, " ")
+	return "// " + s
+}
+
+// commentGroups turns a slice of comments into one *ast.CommentGroup per
+// comment, in source order.
+func commentGroups(cs []comment) []*ast.CommentGroup {
+	groups := make([]*ast.CommentGroup, len(cs))
+	for i, c := range cs {
+		groups[i] = &ast.CommentGroup{List: []*ast.Comment{{Slash: c.Pos, Text: normalizeCommentText(c.Text)}}}
+	}
+	return groups
+}
+
+// isDefForm reports whether v is a top-level `(def ...)`/`(defn ...)` form,
+// the only kind a doc-comment convention can bind to.
+func isDefForm(v Any) bool {
+	list, ok := v.([]Any)
+	if !ok || len(list) == 0 {
+		return false
+	}
+	head, ok := list[0].(astToken)
+	return ok && head.Type == "IDENT" && (head.Value == "def" || head.Value == "defn")
+}
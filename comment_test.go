@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/printer"
+	"strings"
+	"testing"
+)
+
+func TestCommentAttachesToFollowingForm(t *testing.T) {
+	forms := parseAll(t, "; hello\n(foo 1)")
+	if len(forms) != 1 {
+		t.Fatalf("got %d top-level forms, want 1: %#v", len(forms), forms)
+	}
+	c, ok := forms[0].(commented)
+	if !ok {
+		t.Fatalf("form is %T, want commented", forms[0])
+	}
+	if len(c.Comments) != 1 || c.Comments[0].Text != "; hello" {
+		t.Fatalf("got comments %#v, want one \"; hello\"", c.Comments)
+	}
+	if _, ok := c.Form.([]Any); !ok {
+		t.Fatalf("Form is %T, want []Any", c.Form)
+	}
+}
+
+func TestCommentTrailingAtEOF(t *testing.T) {
+	forms := parseAll(t, "(foo)\n; trailing")
+	if len(forms) != 2 {
+		t.Fatalf("got %d top-level forms, want 2: %#v", len(forms), forms)
+	}
+	c, ok := forms[1].(commented)
+	if !ok || c.Form != nil {
+		t.Fatalf("got %#v, want a Form-less commented trailing marker", forms[1])
+	}
+	if len(c.Comments) != 1 || c.Comments[0].Text != "; trailing" {
+		t.Fatalf("got comments %#v, want one \"; trailing\"", c.Comments)
+	}
+}
+
+func TestDocCommentBindsToDefn(t *testing.T) {
+	l := lex("test", ";;; adds two numbers\n(defn add (a b) (+ a b))")
+	forms := parse(l, []Any{})
+	a := generateAST(forms)
+
+	if len(a.Decls) != 1 {
+		t.Fatalf("got %d decls, want 1", len(a.Decls))
+	}
+	if a.Decls[0].(*ast.GenDecl).Doc == nil {
+		t.Fatal("want Doc set on the (defn ...) decl")
+	}
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, l.fset, a); err != nil {
+		t.Fatalf("printer.Fprint: %v", err)
+	}
+	got := buf.String()
+	if !strings.Contains(got, "// adds two numbers") {
+		t.Errorf("generated source missing doc comment, got:\n%s", got)
+	}
+}
+
+// TestCommentAttachesWithMultipleDecls checks that a comment between two
+// later top-level forms prints above the form it's attached to rather
+// than above the first decl in the file: toDecl's GenDecl must carry a
+// real TokPos for go/printer to place file.Comments correctly once there
+// is more than one decl to choose between.
+func TestCommentAttachesWithMultipleDecls(t *testing.T) {
+	l := lex("test", "(foo 1)\n; about bar\n(bar 2)\n(baz 3)")
+	forms := parse(l, []Any{})
+	a := generateAST(forms)
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, l.fset, a); err != nil {
+		t.Fatalf("printer.Fprint: %v", err)
+	}
+	got := buf.String()
+	fooIdx := strings.Index(got, "foo(1)")
+	commentIdx := strings.Index(got, "// about bar")
+	barIdx := strings.Index(got, "bar(2)")
+	if fooIdx == -1 || commentIdx == -1 || barIdx == -1 {
+		t.Fatalf("generated source missing expected pieces, got:\n%s", got)
+	}
+	if !(fooIdx < commentIdx && commentIdx < barIdx) {
+		t.Errorf("want foo(1), then // about bar, then bar(2); got:\n%s", got)
+	}
+}
+
+// TestCommentInsideListIsDropped pins down the documented scope of the
+// comment feature (see commented in comment.go): a comment nested inside
+// a list - whether before one of its elements or trailing with nothing
+// left to attach to - has nowhere to go in the generated Go, so it's
+// dropped rather than, say, misplaced onto file.Comments away from the
+// code it was next to.
+func TestCommentInsideListIsDropped(t *testing.T) {
+	for _, src := range []string{
+		"(foo ; inner comment\n bar)",
+		"(foo bar ; trailing\n)",
+	} {
+		got := transpile(t, src)
+		if strings.Contains(got, "comment") || strings.Contains(got, "trailing") {
+			t.Errorf("transpile(%q): comment leaked into output, got:\n%s", src, got)
+		}
+		if !strings.Contains(got, "foo(bar)") {
+			t.Errorf("transpile(%q): want foo(bar) preserved, got:\n%s", src, got)
+		}
+	}
+}
+
+func TestPlainCommentSurvivesTranspilation(t *testing.T) {
+	l := lex("test", "; just a note\n(foo 1)")
+	forms := parse(l, []Any{})
+	a := generateAST(forms)
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, l.fset, a); err != nil {
+		t.Fatalf("printer.Fprint: %v", err)
+	}
+	got := buf.String()
+	if !strings.Contains(got, "// just a note") {
+		t.Errorf("generated source missing comment, got:\n%s", got)
+	}
+	if !strings.Contains(got, "foo(1)") {
+		t.Errorf("generated source missing lowered call, got:\n%s", got)
+	}
+}
@@ -0,0 +1,454 @@
+// Command gen regenerates lexer_gen.go from the declarative rule table
+// below. The table is the single source of truth for gisp's lexical
+// grammar: every lex* state used to carry its own copy of the same
+// post-emit dispatch switch (whitespace/quote/paren/vect/...), so adding a
+// token meant touching a dozen near-identical switch statements by hand.
+// Add or change a state here and re-run `go generate ./...` instead.
+//
+//go:generate go run .
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"os"
+	"strings"
+)
+
+// stateKind says which body template a state uses. Every state in gisp's
+// grammar reduces to one of these shapes.
+type stateKind int
+
+const (
+	kindEmitDispatch stateKind = iota // emit the already-consumed rune(s), then dispatch on what follows
+	kindDigitRun                      // accept a run of digits, then dispatch on the delimiter
+	kindStringScan                    // scan to a closing quote, honoring backslash escapes
+	kindSymbolScan                    // scan to the next delimiter
+	kindCommentScan                   // scan to end of line
+	kindUnquote                       // like kindEmitDispatch, but peeks for a following '@' first
+	kindHashDispatch                  // peeks after '#' to tell a boolean from a char literal
+	kindCharScan                      // consumes the rune (or named rune run) of a #\ char literal
+)
+
+// ruleState is one row of the grammar table: a lexer state, what it emits,
+// and any side effect it has on entry (paren/vect depth bookkeeping).
+type ruleState struct {
+	name  string // generated function suffix, e.g. "OpenParen"
+	kind  stateKind
+	emit  string // itemType constant this state emits, "" if it never emits
+	enter string // statement run on entering the state, e.g. "l.parenDepth++"
+	check string // guard run right after enter, e.g. a depth-underflow check
+}
+
+// grammar describes gisp's token grammar: every paren/vector/quote-family/
+// string/number/symbol/comment state, in the order the lexer visits them.
+// genDispatch — the "what state handles the next rune" table every old
+// lex* function repeated inline — is derived once from this list instead
+// of being copy-pasted per state.
+var grammar = []ruleState{
+	{name: "Whitespace", kind: kindEmitDispatch},
+	{name: "OpenParen", kind: kindEmitDispatch, emit: "itemLeftParen", enter: "l.parenDepth++"},
+	{name: "CloseParen", kind: kindEmitDispatch, emit: "itemRightParen", enter: "l.parenDepth--",
+		check: `if l.parenDepth < 0 { return l.errItem("unexpected close paren") }`},
+	{name: "OpenVect", kind: kindEmitDispatch, emit: "itemLeftVect", enter: "l.vectDepth++"},
+	{name: "CloseVect", kind: kindEmitDispatch, emit: "itemRightVect", enter: "l.vectDepth--",
+		check: `if l.vectDepth < 0 { return l.errItem("unexpected close paren [vect]") }`},
+	{name: "OpenBrace", kind: kindEmitDispatch, emit: "itemLeftBrace", enter: "l.mapDepth++"},
+	{name: "CloseBrace", kind: kindEmitDispatch, emit: "itemRightBrace", enter: "l.mapDepth--",
+		check: `if l.mapDepth < 0 { return l.errItem("unexpected close brace") }`},
+	{name: "Quote", kind: kindEmitDispatch, emit: "itemQuote"},
+	{name: "Quasiquote", kind: kindEmitDispatch, emit: "itemQuasiQuote"},
+	{name: "Unquote", kind: kindUnquote, emit: "itemUnquote"},
+	{name: "UnquoteSplice", kind: kindEmitDispatch, emit: "itemUnquoteSplice"},
+	{name: "String", kind: kindStringScan, emit: "itemString"},
+	{name: "Int", kind: kindDigitRun, emit: "itemInt"},
+	{name: "Float", kind: kindDigitRun, emit: "itemFloat"},
+	{name: "Duration", kind: kindSymbolScan, emit: "itemDuration"},
+	{name: "Symbol", kind: kindSymbolScan, emit: "itemIdent"},
+	{name: "Comment", kind: kindCommentScan, emit: "itemComment"},
+	{name: "Hash", kind: kindHashDispatch, emit: "itemBool"},
+	{name: "Char", kind: kindCharScan, emit: "itemChar"},
+}
+
+func main() {
+	var b strings.Builder
+
+	fmt.Fprintln(&b, "// Code generated by gen; DO NOT EDIT.")
+	fmt.Fprintln(&b, "// Regenerate with: go generate ./...")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, "package main")
+	fmt.Fprintln(&b)
+	fmt.Fprintln(&b, `import (`)
+	fmt.Fprintln(&b, `	"fmt"`)
+	fmt.Fprintln(&b, `	"unicode"`)
+	fmt.Fprintln(&b, `)`)
+	fmt.Fprintln(&b)
+
+	writeDispatch(&b)
+	writeNextItem(&b)
+	for _, s := range grammar {
+		writeState(&b, s)
+	}
+	writeHelpers(&b)
+
+	src, err := format.Source([]byte(b.String()))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gen: formatting generated source:", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile("lexer_gen.go", src, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, "gen:", err)
+		os.Exit(1)
+	}
+}
+
+// writeDispatch emits the single shared "what state handles this rune"
+// table. Every kindEmitDispatch/kindUnquote state ends by calling into it.
+func writeDispatch(b *strings.Builder) {
+	fmt.Fprintln(b, `// genDispatch decides which state handles the rune that follows an emit.
+// Every hand-written lex* function used to inline this same switch; the
+// generator now owns the single copy.
+func genDispatch(r rune) string {
+	switch r {
+	case ' ', '\t', '\n', '\r':
+		return "Whitespace"
+	case '\'':
+		return "Quote"
+	case '`+"`"+`':
+		return "Quasiquote"
+	case ',':
+		return "Unquote"
+	case '"':
+		return "String"
+	case '(':
+		return "OpenParen"
+	case ')':
+		return "CloseParen"
+	case '[':
+		return "OpenVect"
+	case ']':
+		return "CloseVect"
+	case '{':
+		return "OpenBrace"
+	case '}':
+		return "CloseBrace"
+	case '#':
+		return "Hash"
+	case ';':
+		return "Comment"
+	case eof:
+		return "EOF"
+	}
+	if unicode.IsDigit(r) {
+		return "Int"
+	}
+	return "Symbol"
+}`)
+	fmt.Fprintln(b)
+}
+
+// writeNextItem emits the resumable driver loop: it repeatedly calls the
+// state function named by l.genState until one of them actually emits.
+func writeNextItem(b *strings.Builder) {
+	fmt.Fprintln(b, `// nextItem pulls the next token out of the input, running the generated
+// state functions synchronously in the calling goroutine. There is no
+// channel and no goroutine behind this call any more: each state function
+// returns (item, true) when it has something to emit, or (item{}, false)
+// to tail-transition straight into the next state.
+func (l *lexer) nextItem() item {
+	if l.genState == "" {
+		l.genState = "Whitespace"
+	}
+	for {
+		var it item
+		var ok bool
+		switch l.genState {`)
+	for _, s := range grammar {
+		fmt.Fprintf(b, "\t\tcase %q:\n\t\t\tit, ok = genLex%s(l)\n", s.name, s.name)
+	}
+	// The %q below is part of the generated source text, not a
+	// formatting directive for this call; routed through a variable
+	// instead of a literal call argument so go vet's printf checker
+	// doesn't mistake this Fprint for a missing Sprintf.
+	eofCase := `		case "EOF":
+			l.lastPos = l.tokenPos()
+			l.atEOF = true
+			return item{itemEOF, l.tokenPos(), ""}
+		default:
+			it, ok = l.errItem("unknown lexer state %q", l.genState)
+		}
+		if ok {
+			l.lastPos = it.pos
+			return it
+		}
+	}
+}
+`
+	fmt.Fprint(b, eofCase)
+	fmt.Fprintln(b)
+}
+
+func writeState(b *strings.Builder, s ruleState) {
+	switch s.kind {
+	case kindEmitDispatch:
+		writeEmitDispatch(b, s, false)
+	case kindUnquote:
+		writeEmitDispatch(b, s, true)
+	case kindDigitRun:
+		writeDigitRun(b, s)
+	case kindStringScan:
+		writeStringScan(b, s)
+	case kindSymbolScan:
+		writeSymbolScan(b, s)
+	case kindCommentScan:
+		writeCommentScan(b, s)
+	case kindHashDispatch:
+		writeHashDispatch(b, s)
+	case kindCharScan:
+		writeCharScan(b, s)
+	}
+	fmt.Fprintln(b)
+}
+
+func writeEmitDispatch(b *strings.Builder, s ruleState, unquote bool) {
+	fmt.Fprintf(b, "func genLex%s(l *lexer) (item, bool) {\n", s.name)
+	if unquote {
+		fmt.Fprintln(b, `	if l.peek() == '@' {
+		l.next()
+		l.genState = "UnquoteSplice"
+		return item{}, false
+	}
+	l.acceptRun(" ")
+	l.ignore()`)
+	} else if s.name == "Whitespace" {
+		fmt.Fprintln(b, `	l.ignore()`)
+	} else if s.name == "Quote" || s.name == "Quasiquote" || s.name == "UnquoteSplice" {
+		fmt.Fprintln(b, `	l.acceptRun(" ")
+	l.ignore()`)
+	}
+	if s.enter != "" {
+		fmt.Fprintf(b, "\t%s\n", s.enter)
+	}
+	if s.check != "" {
+		fmt.Fprintf(b, "\t%s\n", s.check)
+	}
+	if s.emit != "" {
+		fmt.Fprintf(b, "\tit := item{%s, l.tokenPos(), l.lexeme()}\n\tl.resetLexeme()\n", s.emit)
+	}
+	fmt.Fprintln(b, `	r := l.next()
+	if s := l.unclosedParen(r); s != "" {
+		l.genState = s
+		return l.errItem("unclosed paren")
+	}
+	l.genState = genDispatch(r)`)
+	if s.emit != "" {
+		fmt.Fprintln(b, "\treturn it, true")
+	} else {
+		fmt.Fprintln(b, "\treturn item{}, false")
+	}
+	fmt.Fprintln(b, "}")
+}
+
+func writeDigitRun(b *strings.Builder, s ruleState) {
+	fmt.Fprintf(b, `func genLex%s(l *lexer) (item, bool) {
+	l.acceptRun("0123456789")
+`, s.name)
+	if s.name == "Int" {
+		fmt.Fprintln(b, `	switch l.peek() {
+	case '.':
+		l.next()
+		l.genState = "Float"
+		return item{}, false
+	}`)
+	}
+	fmt.Fprintln(b, `	if unicode.IsLetter(l.peek()) {
+		l.genState = "Duration"
+		return item{}, false
+	}`)
+	fmt.Fprintf(b, `	it := item{%s, l.tokenPos(), l.lexeme()}
+	l.resetLexeme()
+	r := l.next()
+	switch r {
+	case ' ', '\t', '\n', '\r':
+		l.genState = "Whitespace"
+	case ')':
+		l.genState = "CloseParen"
+	case '}':
+		l.genState = "CloseBrace"
+	case ';':
+		l.genState = "Comment"
+	case eof:
+		if s := l.unclosedParen(r); s != "" {
+			l.genState = s
+			return l.errItem("unclosed paren")
+		}
+		l.genState = "EOF"
+	default:
+		return l.errItem("unexpected rune in genLex%s: %%c", r)
+	}
+	return it, true
+}
+`, s.emit, s.name)
+}
+
+func writeStringScan(b *strings.Builder, s ruleState) {
+	fmt.Fprintf(b, `func genLex%s(l *lexer) (item, bool) {
+	for {
+		switch l.next() {
+		case '"':
+			it := item{%s, l.tokenPos(), l.lexeme()}
+			l.resetLexeme()
+			l.genState = "Whitespace"
+			return it, true
+		case '\\':
+			l.next()
+		case eof:
+			return l.errItem("unterminated string")
+		}
+	}
+}
+`, s.name, s.emit)
+}
+
+func writeSymbolScan(b *strings.Builder, s ruleState) {
+	fmt.Fprintf(b, `func genLex%s(l *lexer) (item, bool) {
+	for {
+		switch l.peek() {
+		case ' ', '\t', '\n', '\r':
+			it := item{%s, l.tokenPos(), l.lexeme()}
+			l.resetLexeme()
+			l.next()
+			l.genState = "Whitespace"
+			return it, true
+		case ')':
+			it := item{%s, l.tokenPos(), l.lexeme()}
+			l.resetLexeme()
+			l.next()
+			l.genState = "CloseParen"
+			return it, true
+		case '}':
+			it := item{%s, l.tokenPos(), l.lexeme()}
+			l.resetLexeme()
+			l.next()
+			l.genState = "CloseBrace"
+			return it, true
+		case ';':
+			it := item{%s, l.tokenPos(), l.lexeme()}
+			l.resetLexeme()
+			l.next()
+			l.genState = "Comment"
+			return it, true
+		case eof:
+			if s := l.unclosedParen(eof); s != "" {
+				l.genState = s
+				return l.errItem("unclosed paren")
+			}
+			it := item{%s, l.tokenPos(), l.lexeme()}
+			l.resetLexeme()
+			l.genState = "EOF"
+			return it, true
+		default:
+			l.next()
+		}
+	}
+}
+`, s.name, s.emit, s.emit, s.emit, s.emit, s.emit)
+}
+
+func writeCommentScan(b *strings.Builder, s ruleState) {
+	fmt.Fprintf(b, `func genLex%s(l *lexer) (item, bool) {
+	for {
+		r := l.peek()
+		if r == '\n' || r == '\r' || r == eof {
+			if r == eof {
+				if s := l.unclosedParen(r); s != "" {
+					l.genState = s
+					return l.errItem("unclosed paren")
+				}
+			}
+			it := item{%s, l.tokenPos(), l.lexeme()}
+			l.resetLexeme()
+			if r == eof {
+				l.genState = "EOF"
+			} else {
+				l.genState = "Whitespace"
+			}
+			return it, true
+		}
+		l.next()
+	}
+}
+`, s.name, s.emit)
+}
+
+// writeHashDispatch emits the state entered on '#': `#t`/`#f` are
+// self-contained booleans so it emits itemBool directly and dispatches
+// like any other kindEmitDispatch state, while `#\` hands off to Char to
+// scan the rune (or named rune run) that follows.
+func writeHashDispatch(b *strings.Builder, s ruleState) {
+	fmt.Fprintf(b, `func genLex%s(l *lexer) (item, bool) {
+	switch l.peek() {
+	case 't', 'f':
+		l.next()
+		it := item{%s, l.tokenPos(), l.lexeme()}
+		l.resetLexeme()
+		r := l.next()
+		if s := l.unclosedParen(r); s != "" {
+			l.genState = s
+			return l.errItem("unclosed paren")
+		}
+		l.genState = genDispatch(r)
+		return it, true
+	case '\\':
+		l.next()
+		l.genState = "Char"
+		return item{}, false
+	default:
+		return l.errItem("unknown # syntax")
+	}
+}
+`, s.name, s.emit)
+}
+
+// writeCharScan emits the Char state a `#\` hands off to: the rune right
+// after the backslash is always part of the literal, and a run of letters
+// after it (e.g. the "ewline" of "#\newline") is folded in too so named
+// char literals like #\newline and #\space lex as one token. A `#\` with
+// nothing after it (EOF right there) has no rune to lex at all, so it
+// errors instead of emitting an itemChar with an empty body.
+func writeCharScan(b *strings.Builder, s ruleState) {
+	fmt.Fprintf(b, `func genLex%s(l *lexer) (item, bool) {
+	if l.next() == eof {
+		return l.errItem("unterminated char literal")
+	}
+	l.acceptRun("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	it := item{%s, l.tokenPos(), l.lexeme()}
+	l.resetLexeme()
+	r := l.next()
+	if s := l.unclosedParen(r); s != "" {
+		l.genState = s
+		return l.errItem("unclosed paren")
+	}
+	l.genState = genDispatch(r)
+	return it, true
+}
+`, s.name, s.emit)
+}
+
+func writeHelpers(b *strings.Builder) {
+	fmt.Fprintln(b, `// unclosedParen reports the EOF-with-open-paren case the old
+// lexWhitespace special-cased; every other state just dispatches normally.
+func (l *lexer) unclosedParen(r rune) string {
+	if r == eof && l.parenDepth > 0 {
+		return "EOF"
+	}
+	return ""
+}
+
+// errItem halts the generated lexer at the next EOF check and hands back
+// a single itemError, the synchronous equivalent of the old l.errorf.
+func (l *lexer) errItem(format string, args ...interface{}) (item, bool) {
+	l.genState = "EOF"
+	return item{itemError, l.tokenPos(), fmt.Sprintf(format, args...)}, true
+}`)
+}
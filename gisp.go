@@ -4,14 +4,11 @@ import (
 	"bufio"
 	"bytes"
 	"fmt"
-	// "go/ast"
 	"go/printer"
 	goToken "go/token"
-	"io/ioutil"
+	"io"
 	"os"
 	"strings"
-	"unicode"
-	"unicode/utf8"
 )
 
 type Any interface{}
@@ -22,7 +19,7 @@ type Pos int
 
 type item struct {
 	typ itemType
-	pos Pos
+	pos goToken.Pos
 	val string
 }
 
@@ -42,44 +39,111 @@ const (
 	itemChar
 	itemFloat
 	itemInt
+	itemBool
+	itemDuration
+
+	itemLeftBrace
+	itemRightBrace
 
 	itemQuote
 	itemQuasiQuote
 	itemUnquote
 	itemUnquoteSplice
+
+	itemComment
 )
 
 const eof = -1
 
 type stateFn func(*lexer) stateFn
 
+//go:generate go run ./gen
+
 type lexer struct {
 	name    string
-	input   string
+	r       io.RuneReader
 	state   stateFn
 	pos     Pos
 	start   Pos
 	width   Pos
-	lastPos Pos
+	lastPos goToken.Pos
 	items   chan item
 
+	// buf accumulates the runes of the lexeme currently being scanned,
+	// since the last ignore/emit. unread/unreadWidths hold runes (and
+	// their byte widths) backed up out of buf that the next call to
+	// next() should hand out again before reading any further from r.
+	buf          []rune
+	unread       []rune
+	unreadWidths []int
+
+	// fset/file back the go/token positions recorded on every item, and
+	// file.AddLine is kept up to date as '\n' is consumed so
+	// fset.Position reports real line:col, not just a byte offset.
+	// byteOff is the current byte offset into file; startByte is the
+	// byte offset resetLexeme last recorded it at.
+	fset      *goToken.FileSet
+	file      *goToken.File
+	byteOff   int
+	startByte int
+
+	// src is the original input lex() was handed, set only when the whole
+	// program is already in memory. When non-empty, lexeme() slices item
+	// values straight out of it instead of allocating a fresh string from
+	// buf; a streamed lexReader (a file or stdin) leaves this empty and
+	// falls back to building the string from buf as before.
+	src string
+
+	// genState is the current state for the generated, channel-free
+	// lexer in lexer_gen.go; see nextItem.
+	genState string
+
+	// atEOF is set once nextItem's "EOF" state has been reached, since
+	// genState then stays "EOF" and every further call just returns
+	// another itemEOF: callers that keep calling parse in a loop (the
+	// REPL in main) need this to tell real end-of-input apart from a
+	// parse call that simply read zero forms before hitting it again.
+	atEOF bool
+
+	// pendingComments holds itemComment items parse has seen but not yet
+	// attached to a form; see attachComments in comment.go.
+	pendingComments []comment
+
 	parenDepth int
 	vectDepth  int
+	mapDepth   int
 }
 
-// next returns the next rune in the input.
+// next returns the next rune from r, or eof once r is exhausted.
 func (l *lexer) next() rune {
-	if int(l.pos) >= len(l.input) {
+	if n := len(l.unread); n > 0 {
+		r := l.unread[n-1]
+		w := l.unreadWidths[n-1]
+		l.unread = l.unread[:n-1]
+		l.unreadWidths = l.unreadWidths[:n-1]
+		l.buf = append(l.buf, r)
+		l.pos++
+		l.width = Pos(w)
+		l.byteOff += w
+		return r
+	}
+
+	r, w, err := l.r.ReadRune()
+	if err != nil {
 		l.width = 0
 		return eof
 	}
-	r, w := utf8.DecodeRuneInString(l.input[l.pos:])
+	l.buf = append(l.buf, r)
+	l.pos++
 	l.width = Pos(w)
-	l.pos += l.width
+	l.byteOff += w
+	if r == '\n' {
+		l.file.AddLine(l.byteOff)
+	}
 	return r
 }
 
-// peek returns but does not consume the next rune in the input.
+// peek returns but does not consume the next rune.
 func (l *lexer) peek() rune {
 	r := l.next()
 	l.backup()
@@ -88,17 +152,52 @@ func (l *lexer) peek() rune {
 
 // backup steps back one rune. Can only be called once per call of next.
 func (l *lexer) backup() {
+	if l.width == 0 {
+		return
+	}
+	n := len(l.buf)
+	l.unread = append(l.unread, l.buf[n-1])
+	l.unreadWidths = append(l.unreadWidths, int(l.width))
+	l.buf = l.buf[:n-1]
 	l.pos -= l.width
+	l.byteOff -= int(l.width)
 }
 
-// emit passes an item back to the client.
+// lexeme returns the text accumulated since the last ignore/emit. When the
+// whole input is in memory (src is set), this slices straight into it
+// instead of allocating, since startByte/byteOff already bound the lexeme
+// exactly; otherwise it builds a string from the streamed runes in buf.
+func (l *lexer) lexeme() string {
+	if l.src != "" {
+		return l.src[l.startByte:l.byteOff]
+	}
+	return string(l.buf)
+}
+
+// tokenPos is the go/token.Pos of the start of the lexeme currently
+// being scanned, suitable for attaching to an item or an *ast node.
+func (l *lexer) tokenPos() goToken.Pos {
+	return l.file.Pos(l.startByte)
+}
+
+// emit passes an item back to the client. It's only used by lexChannel,
+// the goroutine/channel lexer kept around in lexer_chan.go for the
+// benchmark in lex_test.go; the default lex() no longer goes through it.
 func (l *lexer) emit(t itemType) {
-	l.tokens <- item{t, l.start, l.input[l.start:l.pos]}
-	l.start = l.pos
+	l.items <- item{t, l.tokenPos(), l.lexeme()}
+	l.resetLexeme()
 }
 
 func (l *lexer) ignore() {
+	l.resetLexeme()
+}
+
+// resetLexeme marks the current position as the start of the next
+// lexeme, discarding whatever has been accumulated in buf so far.
+func (l *lexer) resetLexeme() {
 	l.start = l.pos
+	l.startByte = l.byteOff
+	l.buf = l.buf[:0]
 }
 
 // accept consumes the next rune if it's from the valid set.
@@ -118,510 +217,237 @@ func (l *lexer) acceptRun(valid string) {
 }
 
 func (l *lexer) errorf(format string, args ...interface{}) stateFn {
-	l.tokens <- item{itemError, l.start, fmt.Sprintf(format, args...)}
+	l.items <- item{itemError, l.tokenPos(), fmt.Sprintf(format, args...)}
 	return nil
 }
 
-func (l *lexer) nextItem() token {
-	item := <-l.items
-	l.lastPos = item.pos
-	return item
-}
-
-func lex(name, input string) *lexer {
-	l := &lexer{
-		name:       name,
-		input:  input,
-		items: make(chan item),
-	}
-	go l.run()
-	return l
-}
-
-func (l *lexer) run() {
-	for l.state = lexWhitespace; l.state != nil; {
-		l.state = l.state(l)
-	}
-	close(l.items)
-}
-
-func lexOpenVect(l *lexer) stateFn {
-	l.emit(_LVECT)
-	l.vectDepth++
-
-	r := l.next()
-
-	switch r {
-	case ' ', '\t', '\n', '\r':
-		return lexWhitespace
-	case '\'':
-		return lexQuote
-	case '`':
-		return lexQuasiquote
-	case ',':
-		return lexUnquote
-	case '(':
-		return lexOpenParen
-	case ')':
-		return lexCloseParen
-	case '[':
-		return lexOpenVect
-	case ']':
-		return lexCloseVect
-	case ';':
-		return lexComment
-	}
-
-	if unicode.IsDigit(r) {
-		return lexInt
-	}
-
-	return lexSymbol
-
+// SyntaxError is a lex/parse failure located in the original source,
+// reported the same file:line:col way the Go toolchain reports its own.
+type SyntaxError struct {
+	Pos goToken.Position
+	Msg string
 }
 
-func lexCloseVect(l *lexer) stateFn {
-	l.emit(_RVECT)
-	l.vectDepth--
-	if l.parenDepth < 0 {
-		return l.errorf("unexpected close paren [vect]")
-	}
-
-	r := l.next()
-
-	switch r {
-	case ' ', '\t', '\n', '\r':
-		return lexWhitespace
-	case '\'':
-		return lexQuote
-	case '`':
-		return lexQuasiquote
-	case ',':
-		return lexUnquote
-	case '(':
-		return lexOpenParen
-	case ')':
-		return lexCloseParen
-	case '[':
-		return lexOpenVect
-	case ']':
-		return lexCloseVect
-	case ';':
-		return lexComment
-	}
-
-	if unicode.IsDigit(r) {
-		return lexInt
-	}
-
-	return lexSymbol
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
 }
 
-// lexes an open parenthesis
-func lexOpenParen(l *lexer) stateFn {
-
-	l.emit(_LPAREN)
-	l.parenDepth++
-
-	r := l.next()
-
-	switch r {
-	case ' ', '\t', '\n', '\r':
-		return lexWhitespace
-	case '\'':
-		return lexQuote
-	case '`':
-		return lexQuasiquote
-	case ',':
-		return lexUnquote
-	case '(':
-		return lexOpenParen
-	case ')':
-		return lexCloseParen
-	case '[':
-		return lexOpenVect
-	case ']':
-		return lexCloseVect
-	case ';':
-		return lexComment
-	}
-
-	if unicode.IsDigit(r) {
-		return lexInt
-	}
-
-	return lexSymbol
+// syntaxErrorf builds a *SyntaxError located at pos, resolved through
+// this lexer's FileSet.
+func (l *lexer) syntaxErrorf(pos goToken.Pos, format string, args ...interface{}) *SyntaxError {
+	return &SyntaxError{Pos: l.fset.Position(pos), Msg: fmt.Sprintf(format, args...)}
 }
 
-func lexQuote(l *lexer) stateFn {
-	l.acceptRun(" ")
-	l.ignore()
-	l.emit(_QUOTE)
-
-	r := l.next()
-
-	switch r {
-	case '"':
-		return lexString
-	case '(':
-		return lexOpenParen
-	case ')':
-		return lexCloseParen
-	case '[':
-		return lexOpenVect
-	case ']':
-		return lexCloseVect
-	case '\'':
-		return lexQuote
-	case '`':
-		return lexQuasiquote
-	case ',':
-		return lexUnquote
-	}
-
-	if unicode.IsDigit(r) {
-		return lexInt
-	}
-
-	return lexSymbol
+// MacroError is an expansion-time failure generateAST's macro-lowering
+// pass raises (e.g. unquote used outside quasiquote; see ast.go), which
+// has no source position of its own to report the way a *SyntaxError
+// does. reportSyntaxError treats it the same as a *SyntaxError: print and
+// exit, rather than a real bug to re-panic.
+type MacroError struct {
+	Msg string
 }
 
-func lexQuasiquote(l *lexer) stateFn {
-	l.acceptRun(" ")
-	l.ignore()
-	l.emit(_QUASIQUOTE)
-
-	r := l.next()
-
-	switch r {
-	case '"':
-		return lexString
-	case '(':
-		return lexOpenParen
-	case ')':
-		return lexCloseParen
-	case '[':
-		return lexOpenVect
-	case ']':
-		return lexCloseVect
-	case '\'':
-		return lexQuote
-	case '`':
-		return lexQuasiquote
-	case ',':
-		return lexUnquote
-	}
-
-	if unicode.IsDigit(r) {
-		return lexInt
-	}
-
-	return lexSymbol
+func (e *MacroError) Error() string {
+	return e.Msg
 }
 
-func lexUnquote(l *lexer) stateFn {
-
-	if l.peek() == '@' {
-		return lexUnquoteSplice
-	}
-
-	l.acceptRun(" ")
-	l.ignore()
-	l.emit(_UNQUOTE)
-
-	r := l.next()
-
-	switch r {
-	case '"':
-		return lexString
-	case '(':
-		return lexOpenParen
-	case ')':
-		return lexCloseParen
-	case '[':
-		return lexOpenVect
-	case ']':
-		return lexCloseVect
-	case '\'':
-		return lexQuote
-	case '`':
-		return lexQuasiquote
-	case ',':
-		return lexUnquote
-	}
-
-	if unicode.IsDigit(r) {
-		return lexInt
-	}
-
-	return lexSymbol
+// lex constructs a lexer over an in-memory string. It's a convenience
+// wrapper around lexReader for callers that already have the whole input
+// in memory, and records input as l.src so lexeme() can slice it directly
+// instead of allocating.
+func lex(name, input string) *lexer {
+	l := lexReader(name, strings.NewReader(input))
+	l.src = input
+	return l
 }
 
-func lexUnquoteSplice(l *lexer) stateFn {
-	r := l.next()
-	l.acceptRun(" ")
-	l.ignore()
-	l.emit(_UNQUOTESPLICE)
-
-	r = l.next()
-
-	switch r {
-	case '"':
-		return lexString
-	case '(':
-		return lexOpenParen
-	case ')':
-		return lexCloseParen
-	case '[':
-		return lexOpenVect
-	case ']':
-		return lexCloseVect
-	case '\'':
-		return lexQuote
-	case '`':
-		return lexQuasiquote
-	case ',':
-		return lexUnquote
+// lexReader constructs a lexer that tokenizes via the generated,
+// single-function state machine in lexer_gen.go, pulling runes from r
+// lazily instead of holding the whole input in memory. nextItem() drives
+// it synchronously, with no goroutine and no channel behind it.
+//
+// r is wrapped in a bufio.Reader only if it doesn't already implement
+// io.RuneReader itself: os.Stdin and os.File need the buffering, but a
+// strings.Reader (what lex() hands in) already decodes runes directly out
+// of the backing string with no further buffering to gain from wrapping
+// it again.
+//
+// The go/token.File backing positions is sized generously up front since
+// a streamed reader doesn't know its total length in advance; offsets
+// within any real .gsp file comfortably fit.
+func lexReader(name string, r io.Reader) *lexer {
+	const maxFileSize = 1 << 30
+	fset := goToken.NewFileSet()
+	rr, ok := r.(io.RuneReader)
+	if !ok {
+		rr = bufio.NewReader(r)
 	}
-
-	if unicode.IsDigit(r) {
-		return lexInt
+	return &lexer{
+		name: name,
+		r:    rr,
+		fset: fset,
+		file: fset.AddFile(name, fset.Base(), maxFileSize),
 	}
-
-	return lexSymbol
 }
 
-func lexWhitespace(l *lexer) stateFn {
-	l.ignore()
-	r := l.next()
+// parse reads items from l until p's enclosing list closes, panicking
+// with a *SyntaxError on any lex or structural error. The top-level
+// caller (args/main) recovers it and reports file:line:col like the Go
+// toolchain does.
+func parse(l *lexer, p []Any) []Any {
 
-	switch r {
-	case ' ', '\t', '\n':
-		return lexWhitespace
-	case '\'':
-		return lexQuote
-	case '`':
-		return lexQuasiquote
-	case ',':
-		return lexUnquote
-	case '"':
-		return lexString
-	case '(':
-		return lexOpenParen
-	case ')':
-		return lexCloseParen
-	case '[':
-		return lexOpenVect
-	case ']':
-		return lexCloseVect
-	case ';':
-		return lexComment
-	case eof:
-		if l.parenDepth > 0 {
-			return l.errorf("unclosed paren")
+	for {
+		t := l.nextItem()
+
+		switch t.typ {
+		case itemEOF:
+			return flushTrailingComments(l, p)
+		case itemError:
+			panic(l.syntaxErrorf(t.pos, "%s", t.val))
+		case itemComment:
+			l.pendingComments = append(l.pendingComments, newComment(t))
+			return parse(l, p)
+		case itemLeftParen:
+			comments := l.takePendingComments()
+			return parse(l, append(p, wrapComments(comments, parse(l, []Any{}))))
+		case itemRightParen:
+			// A comment with nothing left in the list to attach to (e.g.
+			// "(foo bar ; trailing\n)") is dropped here, same as any other
+			// comment nested inside a list; see the commented doc comment
+			// in comment.go for why that's the documented scope of the
+			// feature rather than a silent accident.
+			l.pendingComments = nil
+			return p
+		case itemLeftBrace:
+			comments := l.takePendingComments()
+			return parse(l, append(p, wrapComments(comments, parseMap(l))))
+		case itemRightBrace:
+			l.pendingComments = nil
+			return p
+		case itemQuote, itemQuasiQuote, itemUnquote, itemUnquoteSplice:
+			return parse(l, append(p, attachComments(l, []Any{quoteSymbol(t.typ), readForm(l)})))
+		default:
+			return parse(l, append(p, attachComments(l, itemToAstToken(t))))
 		}
-		l.emit(_EOF)
-		return nil
-	}
-
-	if unicode.IsDigit(r) {
-		return lexInt
-	}
-
-	return lexSymbol
-}
-
-func lexString(l *lexer) stateFn {
-	r := l.next()
-
-	switch r {
-	case '"':
-		l.emit(_STRING)
-		return lexWhitespace
-	case '\\':
-		// l.backup()
-		// l.input = append(l.input[:l.pos], l.input[l.pos+1:])
-		l.next()
-		return lexString
 	}
-
-	return lexString
 }
 
-func lexInt(l *lexer) stateFn {
-	digits := "0123456789"
-	l.acceptRun(digits)
-
-	r := l.peek()
-
-	switch r {
-	case ' ', '\t', '\n':
-		l.emit(_INT)
-		l.next()
-		return lexWhitespace
-	case '.':
-		l.next()
-		return lexFloat
-	case ')':
-		l.emit(_INT)
-		l.next()
-		return lexCloseParen
-	case ';':
-		l.emit(_INT)
-		l.next()
-		return lexComment
-	}
-
-	return l.errorf("unexpected rune in lexInt: %c", r)
+// parseMap reads the alternating key/value sequence of a `{k v k v}`
+// literal and wraps it the same way parse wraps a quote-family form, as
+// []Any{Symbol("hash-map"), k1, v1, k2, v2, ...}, so generateAST's regular
+// form-lowering machinery can turn it into a Go map composite literal.
+func parseMap(l *lexer) []Any {
+	entries := parse(l, []Any{})
+	return append([]Any{Symbol("hash-map")}, entries...)
 }
 
-// once we're in a float, the only valid values are digits, whitespace or close
-// paren.
-func lexFloat(l *lexer) stateFn {
-
-	digits := "0123456789"
-	l.acceptRun(digits)
-
-	l.emit(_FLOAT)
-
-	r := l.next()
-
-	switch r {
-	case ' ', '\t', '\n':
-		return lexWhitespace
-	case ')':
-		return lexCloseParen
-	case ';':
-		return lexComment
+// quoteSymbol names the reader-macro wrapper a quote-family token expands
+// to: 'x, `x, ,x and ,@x all desugar to (quote x)/(quasiquote x)/
+// (unquote x)/(unquote-splice x) the same way a real Lisp reader does.
+func quoteSymbol(typ itemType) Symbol {
+	switch typ {
+	case itemQuote:
+		return Symbol("quote")
+	case itemQuasiQuote:
+		return Symbol("quasiquote")
+	case itemUnquote:
+		return Symbol("unquote")
+	default:
+		return Symbol("unquote-splice")
 	}
-
-	return l.errorf("unexpected run in lexFloat: %c", r)
 }
 
-func lexSymbol(l *lexer) stateFn {
-
-	r := l.peek()
-
-	switch r {
-	case ' ', '\t', '\n':
-		l.emit(_SYMBOL)
-		l.next()
-		return lexWhitespace
-	case ')':
-		l.emit(_SYMBOL)
-		l.next()
-		return lexCloseParen
-	case ';':
-		l.emit(_SYMBOL)
-		l.next()
-		return lexComment
+// readForm reads exactly one form following a quote-family token: an atom,
+// a parenthesized list, or (recursively) another quote-family form, as in
+// `',x` or ``,@x`.
+func readForm(l *lexer) Any {
+	t := l.nextItem()
+	for t.typ == itemComment {
+		t = l.nextItem()
+	}
+	switch t.typ {
+	case itemEOF:
+		panic(l.syntaxErrorf(t.pos, "unexpected EOF after quote"))
+	case itemError:
+		panic(l.syntaxErrorf(t.pos, "%s", t.val))
+	case itemLeftParen:
+		return parse(l, []Any{})
+	case itemRightParen:
+		panic(l.syntaxErrorf(t.pos, "unexpected close paren"))
+	case itemLeftBrace:
+		return parseMap(l)
+	case itemRightBrace:
+		panic(l.syntaxErrorf(t.pos, "unexpected close brace"))
+	case itemQuote, itemQuasiQuote, itemUnquote, itemUnquoteSplice:
+		return []Any{quoteSymbol(t.typ), readForm(l)}
 	default:
-		l.next()
-		return lexSymbol
+		return itemToAstToken(t)
 	}
 }
 
-// lex a close parenthesis
-func lexCloseParen(l *lexer) stateFn {
-	l.emit(_RPAREN)
-	l.parenDepth--
-	if l.parenDepth < 0 {
-		return l.errorf("unexpected close paren")
-	}
-
-	r := l.next()
-	switch r {
-	case ' ', '\t', '\n':
-		return lexWhitespace
-	case '(':
-		return lexOpenParen
-	case ')':
-		return lexCloseParen
-	case '[':
-		return lexOpenVect
-	case ']':
-		return lexCloseVect
-	case ';':
-		return lexComment
-	}
-	return l.errorf("unimplemented")
+// itemToAstToken builds the astToken an atomic item (everything but a
+// paren or a quote-family token) lowers to.
+func itemToAstToken(t item) astToken {
+	v := astToken{Value: t.val, Pos: t.pos}
+	switch t.typ {
+	case itemInt:
+		v.Type = "INT"
+	case itemFloat:
+		v.Type = "FLOAT"
+	case itemString:
+		v.Type = "STRING"
+	case itemIdent:
+		v.Type = "IDENT"
+	case itemBool:
+		v.Type = "BOOL"
+	case itemChar:
+		v.Type = "CHAR"
+	case itemDuration:
+		v.Type = "DURATION"
+	}
+	return v
 }
 
-// lexes a comment
-func lexComment(l *lexer) stateFn {
-
-	r := l.next()
-
-	switch r {
-	case '\n', '\r':
-		return lexWhitespace
+// reportSyntaxError prints a *SyntaxError the way the Go toolchain reports
+// its own errors (file:line:col: message), or a *MacroError from
+// generateAST's macro-expansion pass (e.g. unquote outside quasiquote),
+// then exits. It only matches those two expected-error types rather than
+// the broader error interface: recover() hands back whatever was panicked
+// with, and a runtime.Error (a slice index out of range, say) also
+// satisfies error, so matching on error alone would silently relabel a
+// real bug as if it were expected source input.
+func reportSyntaxError() {
+	r := recover()
+	if r == nil {
+		return
 	}
-	return lexComment
-}
-
-func parse(l *lexer, p []Any) []Any {
-
-	for {
-		t := l.nextToken()
-		if t.typ == _EOF {
-			break
-		} else if t.typ == _INVALID {
-			panic("syntax error")
-		}
-
-		if t.typ == _LPAREN {
-			p = append(p, parse(l, []Any{}))
-			return parse(l, p)
-		} else if t.typ == _RPAREN {
-			return p
-		} else {
-			var v astToken
-			v.Value = t.val
-			switch t.typ {
-			// case _UNQUOTESPLICE:
-			// 	nextExp := parse(l, []Any{})
-			// 	return append(append(p, []Any{Symbol("unquote-splice"), nextExp[0]}), nextExp[1:]...)
-			// case _UNQUOTE:
-			// 	nextExp := parse(l, []Any{})
-			// 	return append(append(p, []Any{Symbol("unquote"), nextExp[0]}), nextExp[1:]...)
-			// case _QUASIQUOTE:
-			// 	nextExp := parse(l, []Any{})
-			// 	return append(append(p, []Any{Symbol("quasiquote"), nextExp[0]}), nextExp[1:]...)
-			// case _QUOTE:
-			// 	nextExp := parse(l, []Any{})
-			// 	return append(append(p, []Any{Symbol("quote"), nextExp[0]}), nextExp[1:]...)
-			case _INT:
-				v.Type = "INT"
-			case _FLOAT:
-				v.Type = "FLOAT"
-			case _STRING:
-				v.Type = "STRING"
-			case _SYMBOL:
-				v.Type = "IDENT"
-			}
-			return parse(l, append(p, v))
-		}
+	switch err := r.(type) {
+	case *SyntaxError:
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	case *MacroError:
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	default:
+		panic(r)
 	}
-
-	return p
 }
 
 func args(filename string) {
-	b, err := ioutil.ReadFile(filename)
+	f, err := os.Open(filename)
 	if err != nil {
 		panic(err)
 	}
-	l := lex(string(b) + "\n")
-	p := parse(l, []Any{})
-	// fmt.Printf("%#v\n\n\n", p)
+	defer f.Close()
 
-	a := generateAST(p)
+	l := lexReader(filename, f)
+	defer reportSyntaxError()
 
-	fset := goToken.NewFileSet()
-	// ast.Print(fset, a)
+	p := parse(l, []Any{})
+	a := generateAST(p)
 
 	var buf bytes.Buffer
-	printer.Fprint(&buf, fset, a)
+	printer.Fprint(&buf, l.fset, a)
 	fmt.Printf("%s\n", buf.String())
 }
 
@@ -631,19 +457,27 @@ func main() {
 		return
 	}
 
-	r := bufio.NewReader(os.Stdin)
+	// One lexer over the whole session: parse pulls runes from stdin as
+	// it needs them, so an expression that opens a paren on one line
+	// keeps reading subsequent lines until it balances, and a bare
+	// Ctrl-D surfaces as eof instead of an empty line.
+	l := lexReader("<stdin>", os.Stdin)
 
 	for {
 		fmt.Print(">> ")
-		line, _, _ := r.ReadLine()
 
-		l := lex(string(line) + "\n")
-		p := parse(l, []Any{})
+		p := func() []Any {
+			defer reportSyntaxError()
+			return parse(l, []Any{})
+		}()
 
 		a := generateAST(p)
-		fset := goToken.NewFileSet()
 		var buf bytes.Buffer
-		printer.Fprint(&buf, fset, a)
+		printer.Fprint(&buf, l.fset, a)
 		fmt.Printf("%s\n", buf.String())
+
+		if l.atEOF {
+			return
+		}
 	}
 }
@@ -0,0 +1,124 @@
+package main
+
+import "testing"
+
+// drain runs a lexer to completion and returns the items it produced,
+// stopping at the first itemEOF or itemError.
+func drain(l *lexer) []item {
+	var out []item
+	for {
+		it := l.nextItem()
+		out = append(out, it)
+		if it.typ == itemEOF || it.typ == itemError {
+			break
+		}
+	}
+	return out
+}
+
+func TestLexGenerated(t *testing.T) {
+	l := lex("test", "(foo 1 2.5 \"bar\")\n")
+	items := drain(l)
+
+	want := []itemType{
+		itemLeftParen, itemIdent, itemInt, itemFloat, itemString, itemRightParen, itemEOF,
+	}
+	if len(items) != len(want) {
+		t.Fatalf("got %d items, want %d: %+v", len(items), len(want), items)
+	}
+	for i, typ := range want {
+		if items[i].typ != typ {
+			t.Errorf("item %d: got type %d, want %d (%+v)", i, items[i].typ, typ, items[i])
+		}
+	}
+}
+
+// TestLexUnclosedParenMidScan checks that an open paren whose last token
+// is cut off by EOF mid-scan (an identifier, duration, or comment, rather
+// than right after a delimiter) reports the same unclosed-paren error a
+// bare "(" at EOF does, instead of silently accepting the truncated token.
+func TestLexUnclosedParenMidScan(t *testing.T) {
+	for _, src := range []string{"(foo", "(; comment", "(foo.5", "(250ms"} {
+		items := drain(lex("test", src))
+		last := items[len(items)-1]
+		if last.typ != itemError {
+			t.Errorf("lex(%q): got last item %+v, want itemError", src, last)
+		}
+	}
+}
+
+// TestLexSymbolAtEOFWithoutOpenParen checks the converse: a token that
+// simply ends at EOF with no enclosing paren lexes and emits normally,
+// rather than being mistaken for the unclosed-paren case above.
+func TestLexSymbolAtEOFWithoutOpenParen(t *testing.T) {
+	items := drain(lex("test", "42"))
+	want := []itemType{itemInt, itemEOF}
+	if len(items) != len(want) {
+		t.Fatalf("got %d items, want %d: %+v", len(items), len(want), items)
+	}
+	for i, typ := range want {
+		if items[i].typ != typ {
+			t.Errorf("item %d: got type %d, want %d (%+v)", i, items[i].typ, typ, items[i])
+		}
+	}
+}
+
+// TestLexAtEOFStaysSet checks that atEOF latches once nextItem reaches
+// end-of-input and stays set on further calls, rather than only being true
+// momentarily: callers like main's REPL loop rely on it to stop looping
+// instead of re-entering the "EOF" state forever.
+func TestLexAtEOFStaysSet(t *testing.T) {
+	l := lex("test", "42")
+	if l.atEOF {
+		t.Fatal("atEOF set before any input was read")
+	}
+	for l.nextItem().typ != itemEOF {
+	}
+	if !l.atEOF {
+		t.Fatal("atEOF not set after reaching EOF")
+	}
+	l.nextItem()
+	if !l.atEOF {
+		t.Fatal("atEOF cleared by a further call past EOF")
+	}
+}
+
+const benchInput = `(defn fib (n)
+  (if (< n 2)
+    n
+    (+ (fib (- n 1)) (fib (- n 2)))))
+`
+
+// BenchmarkLexChannel exercises the original goroutine/channel lexer kept
+// in lexer_chan.go.
+func BenchmarkLexChannel(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l := lexChannel("bench", benchInput)
+		for {
+			it := <-l.items
+			if it.typ == itemEOF || it.typ == itemError {
+				break
+			}
+		}
+	}
+}
+
+// BenchmarkLexGenerated exercises the table-generated lexer in
+// lexer_gen.go. It should run several times faster than
+// BenchmarkLexChannel and allocate substantially less per op, having
+// neither the channel sends and goroutine scheduling of the old lexer
+// nor its own per-token string allocation (lexeme() slices l.src
+// directly when the whole input is already in memory).
+func BenchmarkLexGenerated(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		l := lex("bench", benchInput)
+		for {
+			it := l.nextItem()
+			if it.typ == itemEOF || it.typ == itemError {
+				break
+			}
+		}
+	}
+}
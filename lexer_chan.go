@@ -0,0 +1,446 @@
+package main
+
+import (
+	goToken "go/token"
+	"strings"
+	"unicode"
+)
+
+// lexChannel is the original goroutine/channel lexer, kept only so
+// lex_test.go can benchmark it against the generated lexer in
+// lexer_gen.go. New code should use lex(), not this.
+func lexChannel(name, input string) *lexer {
+	const maxFileSize = 1 << 30
+	fset := goToken.NewFileSet()
+	l := &lexer{
+		name:  name,
+		r:     strings.NewReader(input),
+		fset:  fset,
+		file:  fset.AddFile(name, fset.Base(), maxFileSize),
+		items: make(chan item),
+	}
+	go l.runChannel()
+	return l
+}
+
+func (l *lexer) runChannel() {
+	for l.state = lexWhitespace; l.state != nil; {
+		l.state = l.state(l)
+	}
+	close(l.items)
+}
+
+func lexOpenVect(l *lexer) stateFn {
+	l.emit(itemLeftVect)
+	l.vectDepth++
+
+	r := l.next()
+
+	switch r {
+	case ' ', '\t', '\n', '\r':
+		return lexWhitespace
+	case '\'':
+		return lexQuote
+	case '`':
+		return lexQuasiquote
+	case ',':
+		return lexUnquote
+	case '(':
+		return lexOpenParen
+	case ')':
+		return lexCloseParen
+	case '[':
+		return lexOpenVect
+	case ']':
+		return lexCloseVect
+	case ';':
+		return lexComment
+	}
+
+	if unicode.IsDigit(r) {
+		return lexInt
+	}
+
+	return lexSymbol
+
+}
+
+func lexCloseVect(l *lexer) stateFn {
+	l.emit(itemRightVect)
+	l.vectDepth--
+	if l.parenDepth < 0 {
+		return l.errorf("unexpected close paren [vect]")
+	}
+
+	r := l.next()
+
+	switch r {
+	case ' ', '\t', '\n', '\r':
+		return lexWhitespace
+	case '\'':
+		return lexQuote
+	case '`':
+		return lexQuasiquote
+	case ',':
+		return lexUnquote
+	case '(':
+		return lexOpenParen
+	case ')':
+		return lexCloseParen
+	case '[':
+		return lexOpenVect
+	case ']':
+		return lexCloseVect
+	case ';':
+		return lexComment
+	}
+
+	if unicode.IsDigit(r) {
+		return lexInt
+	}
+
+	return lexSymbol
+}
+
+// lexes an open parenthesis
+func lexOpenParen(l *lexer) stateFn {
+
+	l.emit(itemLeftParen)
+	l.parenDepth++
+
+	r := l.next()
+
+	switch r {
+	case ' ', '\t', '\n', '\r':
+		return lexWhitespace
+	case '\'':
+		return lexQuote
+	case '`':
+		return lexQuasiquote
+	case ',':
+		return lexUnquote
+	case '(':
+		return lexOpenParen
+	case ')':
+		return lexCloseParen
+	case '[':
+		return lexOpenVect
+	case ']':
+		return lexCloseVect
+	case ';':
+		return lexComment
+	}
+
+	if unicode.IsDigit(r) {
+		return lexInt
+	}
+
+	return lexSymbol
+}
+
+func lexQuote(l *lexer) stateFn {
+	l.acceptRun(" ")
+	l.ignore()
+	l.emit(itemQuote)
+
+	r := l.next()
+
+	switch r {
+	case '"':
+		return lexString
+	case '(':
+		return lexOpenParen
+	case ')':
+		return lexCloseParen
+	case '[':
+		return lexOpenVect
+	case ']':
+		return lexCloseVect
+	case '\'':
+		return lexQuote
+	case '`':
+		return lexQuasiquote
+	case ',':
+		return lexUnquote
+	}
+
+	if unicode.IsDigit(r) {
+		return lexInt
+	}
+
+	return lexSymbol
+}
+
+func lexQuasiquote(l *lexer) stateFn {
+	l.acceptRun(" ")
+	l.ignore()
+	l.emit(itemQuasiQuote)
+
+	r := l.next()
+
+	switch r {
+	case '"':
+		return lexString
+	case '(':
+		return lexOpenParen
+	case ')':
+		return lexCloseParen
+	case '[':
+		return lexOpenVect
+	case ']':
+		return lexCloseVect
+	case '\'':
+		return lexQuote
+	case '`':
+		return lexQuasiquote
+	case ',':
+		return lexUnquote
+	}
+
+	if unicode.IsDigit(r) {
+		return lexInt
+	}
+
+	return lexSymbol
+}
+
+func lexUnquote(l *lexer) stateFn {
+
+	if l.peek() == '@' {
+		return lexUnquoteSplice
+	}
+
+	l.acceptRun(" ")
+	l.ignore()
+	l.emit(itemUnquote)
+
+	r := l.next()
+
+	switch r {
+	case '"':
+		return lexString
+	case '(':
+		return lexOpenParen
+	case ')':
+		return lexCloseParen
+	case '[':
+		return lexOpenVect
+	case ']':
+		return lexCloseVect
+	case '\'':
+		return lexQuote
+	case '`':
+		return lexQuasiquote
+	case ',':
+		return lexUnquote
+	}
+
+	if unicode.IsDigit(r) {
+		return lexInt
+	}
+
+	return lexSymbol
+}
+
+func lexUnquoteSplice(l *lexer) stateFn {
+	r := l.next()
+	l.acceptRun(" ")
+	l.ignore()
+	l.emit(itemUnquoteSplice)
+
+	r = l.next()
+
+	switch r {
+	case '"':
+		return lexString
+	case '(':
+		return lexOpenParen
+	case ')':
+		return lexCloseParen
+	case '[':
+		return lexOpenVect
+	case ']':
+		return lexCloseVect
+	case '\'':
+		return lexQuote
+	case '`':
+		return lexQuasiquote
+	case ',':
+		return lexUnquote
+	}
+
+	if unicode.IsDigit(r) {
+		return lexInt
+	}
+
+	return lexSymbol
+}
+
+func lexWhitespace(l *lexer) stateFn {
+	l.ignore()
+	r := l.next()
+
+	switch r {
+	case ' ', '\t', '\n':
+		return lexWhitespace
+	case '\'':
+		return lexQuote
+	case '`':
+		return lexQuasiquote
+	case ',':
+		return lexUnquote
+	case '"':
+		return lexString
+	case '(':
+		return lexOpenParen
+	case ')':
+		return lexCloseParen
+	case '[':
+		return lexOpenVect
+	case ']':
+		return lexCloseVect
+	case ';':
+		return lexComment
+	case eof:
+		if l.parenDepth > 0 {
+			return l.errorf("unclosed paren")
+		}
+		l.emit(itemEOF)
+		return nil
+	}
+
+	if unicode.IsDigit(r) {
+		return lexInt
+	}
+
+	return lexSymbol
+}
+
+func lexString(l *lexer) stateFn {
+	r := l.next()
+
+	switch r {
+	case '"':
+		l.emit(itemString)
+		return lexWhitespace
+	case '\\':
+		// l.backup()
+		// l.input = append(l.input[:l.pos], l.input[l.pos+1:])
+		l.next()
+		return lexString
+	}
+
+	return lexString
+}
+
+func lexInt(l *lexer) stateFn {
+	digits := "0123456789"
+	l.acceptRun(digits)
+
+	r := l.peek()
+
+	switch r {
+	case ' ', '\t', '\n':
+		l.emit(itemInt)
+		l.next()
+		return lexWhitespace
+	case '.':
+		l.next()
+		return lexFloat
+	case ')':
+		l.emit(itemInt)
+		l.next()
+		return lexCloseParen
+	case ';':
+		l.emit(itemInt)
+		l.next()
+		return lexComment
+	}
+
+	return l.errorf("unexpected rune in lexInt: %c", r)
+}
+
+// once we're in a float, the only valid values are digits, whitespace or close
+// paren.
+func lexFloat(l *lexer) stateFn {
+
+	digits := "0123456789"
+	l.acceptRun(digits)
+
+	l.emit(itemFloat)
+
+	r := l.next()
+
+	switch r {
+	case ' ', '\t', '\n':
+		return lexWhitespace
+	case ')':
+		return lexCloseParen
+	case ';':
+		return lexComment
+	}
+
+	return l.errorf("unexpected run in lexFloat: %c", r)
+}
+
+func lexSymbol(l *lexer) stateFn {
+
+	r := l.peek()
+
+	switch r {
+	case ' ', '\t', '\n':
+		l.emit(itemIdent)
+		l.next()
+		return lexWhitespace
+	case ')':
+		l.emit(itemIdent)
+		l.next()
+		return lexCloseParen
+	case ';':
+		l.emit(itemIdent)
+		l.next()
+		return lexComment
+	default:
+		l.next()
+		return lexSymbol
+	}
+}
+
+// lex a close parenthesis
+func lexCloseParen(l *lexer) stateFn {
+	l.emit(itemRightParen)
+	l.parenDepth--
+	if l.parenDepth < 0 {
+		return l.errorf("unexpected close paren")
+	}
+
+	r := l.next()
+	switch r {
+	case ' ', '\t', '\n':
+		return lexWhitespace
+	case '(':
+		return lexOpenParen
+	case ')':
+		return lexCloseParen
+	case '[':
+		return lexOpenVect
+	case ']':
+		return lexCloseVect
+	case ';':
+		return lexComment
+	}
+	return l.errorf("unimplemented")
+}
+
+// lexes a comment
+func lexComment(l *lexer) stateFn {
+
+	r := l.next()
+
+	switch r {
+	case '\n', '\r':
+		return lexWhitespace
+	}
+	return lexComment
+}
+
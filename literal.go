@@ -0,0 +1,175 @@
+package main
+
+import (
+	"go/ast"
+	goToken "go/token"
+	"strconv"
+	"strings"
+)
+
+// boolExpr lowers a `#t`/`#f` literal to the Go `true`/`false` ident it
+// denotes; gisp has no separate boolean type of its own.
+func boolExpr(t astToken) ast.Expr {
+	name := "false"
+	if t.Value == "#t" {
+		name = "true"
+	}
+	id := ast.NewIdent(name)
+	id.NamePos = t.Pos
+	return id
+}
+
+// namedChars maps the multi-letter spellings `#\` accepts (beyond a bare
+// rune like `#\a`) to the rune they denote.
+var namedChars = map[string]rune{
+	"newline": '\n',
+	"space":   ' ',
+	"tab":     '\t',
+	"return":  '\r',
+	"nul":     0,
+}
+
+// charExpr lowers a `#\a` or named `#\newline` char literal to the rune
+// literal it denotes.
+func charExpr(t astToken) ast.Expr {
+	name := strings.TrimPrefix(t.Value, `#\`)
+	r := []rune(name)[0]
+	if named, ok := namedChars[name]; ok {
+		r = named
+	}
+	return &ast.BasicLit{ValuePos: t.Pos, Kind: goToken.CHAR, Value: strconv.QuoteRune(r)}
+}
+
+// durationUnits maps the unit suffix gisp's duration literals accept to
+// the time.Duration constant it lowers to.
+var durationUnits = map[string]string{
+	"ns": "Nanosecond",
+	"us": "Microsecond",
+	"µs": "Microsecond",
+	"ms": "Millisecond",
+	"s":  "Second",
+	"m":  "Minute",
+	"h":  "Hour",
+}
+
+// durationPart is one amount/unit pair scanned out of a compound duration
+// literal like "2h45m" (which scans to [{"2","h"} {"45","m"}]).
+type durationPart struct {
+	amount string
+	unit   string
+}
+
+// durationExpr lowers a gisp duration literal such as "250ms" or "2h45m"
+// to the Go expression built from time.Duration constants that has the
+// same value: a single unit becomes `250 * time.Millisecond`, a compound
+// one becomes a sum of those, e.g. `2*time.Hour + 45*time.Minute`.
+func durationExpr(t astToken) ast.Expr {
+	parts := scanDurationParts(t.Value)
+	expr := durationPartExpr(parts[0], t.Pos)
+	for _, p := range parts[1:] {
+		expr = &ast.BinaryExpr{X: expr, Op: goToken.ADD, Y: durationPartExpr(p, t.Pos)}
+	}
+	return expr
+}
+
+// scanDurationParts splits a duration literal's text into its amount/unit
+// pairs in source order.
+func scanDurationParts(s string) []durationPart {
+	var parts []durationPart
+	for len(s) > 0 {
+		i := 0
+		for i < len(s) && (isDigit(s[i]) || s[i] == '.') {
+			i++
+		}
+		amount := s[:i]
+		s = s[i:]
+		j := 0
+		for j < len(s) && !isDigit(s[j]) {
+			j++
+		}
+		parts = append(parts, durationPart{amount: amount, unit: s[:j]})
+		s = s[j:]
+	}
+	return parts
+}
+
+func isDigit(b byte) bool { return b >= '0' && b <= '9' }
+
+// durationPartExpr lowers one amount/unit pair to `amount * time.Unit`,
+// falling back to Nanosecond for a unit scanDurationParts didn't recognize.
+func durationPartExpr(p durationPart, pos goToken.Pos) ast.Expr {
+	name, ok := durationUnits[p.unit]
+	if !ok {
+		name = "Nanosecond"
+	}
+	kind := goToken.INT
+	if strings.Contains(p.amount, ".") {
+		kind = goToken.FLOAT
+	}
+	amount := &ast.BasicLit{ValuePos: pos, Kind: kind, Value: p.amount}
+	unit := &ast.SelectorExpr{X: ast.NewIdent("time"), Sel: ast.NewIdent(name)}
+	return &ast.BinaryExpr{X: amount, Op: goToken.MUL, Y: unit}
+}
+
+// hashMapForm reports whether list is the `[]Any{Symbol("hash-map"), k1,
+// v1, ...}` wrapper parseMap builds for a `{k v k v}` literal, returning
+// its flattened key/value entries.
+func hashMapForm(list []Any) ([]Any, bool) {
+	if len(list) == 0 {
+		return nil, false
+	}
+	sym, ok := list[0].(Symbol)
+	if !ok || sym != "hash-map" {
+		return nil, false
+	}
+	return list[1:], true
+}
+
+// hashMapExpr lowers a `{k v k v}` literal's flattened key/value entries
+// to a `map[interface{}]interface{}{...}` composite literal. An odd number
+// of entries means the literal's last key has no matching value, which is
+// a lowering error rather than something to silently truncate away.
+func hashMapExpr(entries []Any) ast.Expr {
+	if len(entries)%2 != 0 {
+		panic(&MacroError{Msg: "hash-map literal has a key with no matching value"})
+	}
+	elts := make([]ast.Expr, 0, len(entries)/2)
+	for i := 0; i+1 < len(entries); i += 2 {
+		elts = append(elts, &ast.KeyValueExpr{Key: toExpr(entries[i]), Value: toExpr(entries[i+1])})
+	}
+	return &ast.CompositeLit{Type: interfaceMapType(), Elts: elts}
+}
+
+// interfaceMapType is the `map[interface{}]interface{}` go/ast spells out
+// wherever a `{...}` literal is lowered to a composite literal.
+func interfaceMapType() *ast.MapType {
+	return &ast.MapType{
+		Key:   &ast.InterfaceType{Methods: &ast.FieldList{}},
+		Value: &ast.InterfaceType{Methods: &ast.FieldList{}},
+	}
+}
+
+// addTimeImportIfNeeded adds an `import "time"` declaration to file if
+// generateAST lowered any duration literal into a time.Duration
+// expression, since a .gsp program never spells "time" itself for
+// generateAST to have already imported it another way.
+func addTimeImportIfNeeded(file *ast.File) {
+	uses := false
+	ast.Inspect(file, func(n ast.Node) bool {
+		if uses {
+			return false
+		}
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok && id.Name == "time" {
+				uses = true
+			}
+		}
+		return true
+	})
+	if !uses {
+		return
+	}
+	spec := &ast.ImportSpec{Path: &ast.BasicLit{Kind: goToken.STRING, Value: strconv.Quote("time")}}
+	file.Imports = append(file.Imports, spec)
+	file.Decls = append([]ast.Decl{&ast.GenDecl{Tok: goToken.IMPORT, Specs: []ast.Spec{spec}}}, file.Decls...)
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"go/printer"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLexBoolCharMapDuration(t *testing.T) {
+	l := lex("test", "(#t #f #\\a #\\newline {1 2} 250ms)")
+	items := drain(l)
+
+	want := []itemType{
+		itemLeftParen, itemBool, itemBool, itemChar, itemChar,
+		itemLeftBrace, itemInt, itemInt, itemRightBrace, itemDuration,
+		itemRightParen, itemEOF,
+	}
+	if len(items) != len(want) {
+		t.Fatalf("got %d items, want %d: %+v", len(items), len(want), items)
+	}
+	for i, typ := range want {
+		if items[i].typ != typ {
+			t.Errorf("item %d: got type %d, want %d (%+v)", i, items[i].typ, typ, items[i])
+		}
+	}
+}
+
+// TestLexUnterminatedCharLiteral checks that a `#\` with nothing after it
+// (EOF right at the backslash) lexes to an itemError instead of an
+// itemChar with an empty body, which used to reach charExpr and panic
+// with an out-of-range slice index.
+func TestLexUnterminatedCharLiteral(t *testing.T) {
+	items := drain(lex("test", `#\`))
+	last := items[len(items)-1]
+	if last.typ != itemError {
+		t.Fatalf("got last item %+v, want itemError", last)
+	}
+}
+
+func TestParseHashMap(t *testing.T) {
+	forms := parseAll(t, "{1 2 3 4}")
+	want := []Any{[]Any{
+		Symbol("hash-map"), intLit("1"), intLit("2"), intLit("3"), intLit("4"),
+	}}
+	if got := stripPosAll(forms); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+}
+
+func transpile(t *testing.T, src string) string {
+	t.Helper()
+	l := lex("test", src)
+	forms := parse(l, []Any{})
+	a := generateAST(forms)
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, l.fset, a); err != nil {
+		t.Fatalf("printer.Fprint: %v", err)
+	}
+	return buf.String()
+}
+
+func TestGenerateASTBool(t *testing.T) {
+	got := transpile(t, "#t")
+	if !strings.Contains(got, "true") {
+		t.Errorf("generated source missing true, got:\n%s", got)
+	}
+}
+
+func TestGenerateASTChar(t *testing.T) {
+	for _, tt := range []struct {
+		src, want string
+	}{
+		{`#\a`, "'a'"},
+		{`#\newline`, `'\n'`},
+	} {
+		if got := transpile(t, tt.src); !strings.Contains(got, tt.want) {
+			t.Errorf("transpile(%q): missing %q, got:\n%s", tt.src, tt.want, got)
+		}
+	}
+}
+
+// TestGenerateASTHashMapOddEntries checks that a `{...}` literal with an
+// odd number of elements raises a *MacroError instead of silently
+// truncating its last, unpaired key.
+func TestGenerateASTHashMapOddEntries(t *testing.T) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("expected panic for an odd-length hash-map literal")
+		}
+		if _, ok := r.(*MacroError); !ok {
+			t.Fatalf("got panic %#v (%T), want *MacroError", r, r)
+		}
+	}()
+	l := lex("test", "{1 2 3}")
+	forms := parse(l, []Any{})
+	generateAST(forms)
+}
+
+func TestGenerateASTHashMap(t *testing.T) {
+	got := transpile(t, `{1 2 3 4}`)
+	for _, want := range []string{"map[interface {", "1: 2", "3: 4"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("generated source missing %q, got:\n%s", want, got)
+		}
+	}
+}
+
+// TestGenerateASTDuration checks both a single-unit and a compound
+// duration literal lower to time.Duration arithmetic, and that doing so
+// adds the "time" import the generated expression now depends on.
+func TestGenerateASTDuration(t *testing.T) {
+	got := transpile(t, "250ms")
+	for _, want := range []string{`"time"`, "250 * time.Millisecond"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("generated source missing %q, got:\n%s", want, got)
+		}
+	}
+
+	got = transpile(t, "2h45m")
+	for _, want := range []string{"2*time.Hour", "45*time.Minute"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("generated source missing %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestGenerateASTNoTimeImportWithoutDuration(t *testing.T) {
+	got := transpile(t, "(foo 1)")
+	if strings.Contains(got, `"time"`) {
+		t.Errorf("generated source has unwanted time import, got:\n%s", got)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bytes"
+	"go/printer"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// parseAll lexes and parses a whole program, discarding the lexer (tests
+// only need the resulting forms).
+func parseAll(t *testing.T, src string) []Any {
+	t.Helper()
+	l := lex("test", src)
+	return parse(l, []Any{})
+}
+
+// ident/atom helpers keep the want-side of these tests readable: they
+// build the astToken shapes parse itself produces, without caring about
+// the exact Pos each one carries.
+func ident(name string) astToken { return astToken{Value: name, Type: "IDENT"} }
+func intLit(val string) astToken { return astToken{Value: val, Type: "INT"} }
+func stripPos(v Any) Any {
+	switch t := v.(type) {
+	case astToken:
+		t.Pos = 0
+		return t
+	case []Any:
+		out := make([]Any, len(t))
+		for i, e := range t {
+			out[i] = stripPos(e)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func TestParseQuoteAtom(t *testing.T) {
+	forms := parseAll(t, "'foo")
+	want := []Any{[]Any{Symbol("quote"), ident("foo")}}
+	if got := stripPosAll(forms); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestParseQuoteList(t *testing.T) {
+	forms := parseAll(t, "'(1 2 3)")
+	want := []Any{[]Any{Symbol("quote"), []Any{intLit("1"), intLit("2"), intLit("3")}}}
+	if got := stripPosAll(forms); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestParseQuasiquoteUnquoteSplice(t *testing.T) {
+	forms := parseAll(t, "`(a ,b ,@c d)")
+	want := []Any{[]Any{Symbol("quasiquote"), []Any{
+		ident("a"),
+		[]Any{Symbol("unquote"), ident("b")},
+		[]Any{Symbol("unquote-splice"), ident("c")},
+		ident("d"),
+	}}}
+	if got := stripPosAll(forms); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+}
+
+func TestParseNestedQuasiquote(t *testing.T) {
+	forms := parseAll(t, "`(a `(b ,c))")
+	want := []Any{[]Any{Symbol("quasiquote"), []Any{
+		ident("a"),
+		[]Any{Symbol("quasiquote"), []Any{
+			ident("b"),
+			[]Any{Symbol("unquote"), ident("c")},
+		}},
+	}}}
+	if got := stripPosAll(forms); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v, want %#v", got, want)
+	}
+}
+
+// TestGenerateASTQuasiquoteSplice is a transpile round-trip: it checks
+// that `(a ,b ,@c d) lowers to Go source that builds its result with
+// append and a variadic spread, rather than panicking or dropping the
+// splice.
+func TestGenerateASTQuasiquoteSplice(t *testing.T) {
+	l := lex("test", "`(a ,b ,@c d)")
+	forms := parse(l, []Any{})
+	a := generateAST(forms)
+
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, l.fset, a); err != nil {
+		t.Fatalf("printer.Fprint: %v", err)
+	}
+	got := buf.String()
+	for _, want := range []string{"append(", "c...", "b", `"a"`, `"d"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("generated source missing %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestGenerateASTUnquoteOutsideQuasiquote(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic for unquote outside quasiquote")
+		}
+	}()
+	l := lex("test", ",x")
+	forms := parse(l, []Any{})
+	generateAST(forms)
+}
+
+// TestGenerateASTUnquoteSpliceOutsideList checks that `,@x` at the top
+// level (no enclosing list to splice into) raises a *MacroError the same
+// way unquote/unquote-splice outside quasiquote does, rather than an
+// unhandled panic with a raw Go stack trace.
+func TestGenerateASTUnquoteSpliceOutsideList(t *testing.T) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			t.Fatal("expected panic for unquote-splice outside a list")
+		}
+		if _, ok := r.(*MacroError); !ok {
+			t.Fatalf("got panic %#v (%T), want *MacroError", r, r)
+		}
+	}()
+	l := lex("test", "`,@x")
+	forms := parse(l, []Any{})
+	generateAST(forms)
+}
+
+// TestGenerateASTNestedQuasiquote is a transpile round-trip for
+// `` `(a `(b ,c)) ``: the inner quasiquote's ,c is two levels deep with no
+// matching outer unquote, so it must stay quoted data - "c" as a literal
+// string, not the live variable c - and the nested "quasiquote"/"unquote"
+// symbols must themselves survive as quoted data instead of turning into
+// a stray nil.
+func TestGenerateASTNestedQuasiquote(t *testing.T) {
+	got := transpile(t, "`(a `(b ,c))")
+	for _, want := range []string{`"a"`, `"quasiquote"`, `"b"`, `"unquote"`, `"c"`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("generated source missing %q, got:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "nil") {
+		t.Errorf("generated source has a stray nil, got:\n%s", got)
+	}
+}
+
+// stripPosAll strips Pos from every astToken in forms so tests can compare
+// against literal want values without reproducing byte offsets.
+func stripPosAll(forms []Any) []Any {
+	out := make([]Any, len(forms))
+	for i, f := range forms {
+		out[i] = stripPos(f)
+	}
+	return out
+}
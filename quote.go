@@ -0,0 +1,138 @@
+package main
+
+import (
+	"go/ast"
+	"strconv"
+)
+
+// quoteExpr lowers the argument of a top-level `(quote x)` form to Go: a
+// quoted list becomes a `[]interface{}{...}` composite literal of its
+// (recursively quoted) elements, a quoted symbol becomes the string literal
+// of its name (Go has no symbol type), and any other atom is self-quoting.
+func quoteExpr(v Any) ast.Expr {
+	switch t := v.(type) {
+	case commented:
+		return quoteExpr(t.Form)
+	case astToken:
+		if t.Type == "IDENT" {
+			return &ast.BasicLit{ValuePos: t.Pos, Kind: litKind("STRING"), Value: strconv.Quote(t.Value)}
+		}
+		return tokenExpr(t)
+	case []Any:
+		elts := make([]ast.Expr, len(t))
+		for i, e := range t {
+			elts[i] = quoteExpr(e)
+		}
+		return &ast.CompositeLit{Type: interfaceSliceType(), Elts: elts}
+	default:
+		return ast.NewIdent("nil")
+	}
+}
+
+// quasiquoteExpr implements the standard quasiquote expansion: an atom is
+// self-quoting, a bare `(unquote x)` form appearing where the quasiquoted
+// value itself is expected unquotes to x, and a list builds up a
+// `[]interface{}{...}` by appending each element in turn - re-quasiquoting
+// ordinary elements, substituting unquoted ones directly, and splicing
+// unquote-splice ones in with Go's variadic append. The form immediately
+// inside a `(quasiquote x)` is at depth 1; see quasiquoteAt for how nesting
+// is tracked from there.
+func quasiquoteExpr(v Any) ast.Expr {
+	return quasiquoteAt(v, 1)
+}
+
+// quasiquoteAt expands v at the given quasiquote nesting depth, following
+// the standard recursive quasiquote algorithm: walking into a nested
+// `(quasiquote x)` increments depth, walking into a matching
+// `(unquote x)`/`(unquote-splice x)` decrements it, and an unquote or
+// unquote-splice only actually substitutes/splices once depth has come
+// back down to 1 - anything still nested inside an unexpanded quasiquote
+// stays quoted data, re-expanded at its own (lower) depth so an unquote
+// further inside can still eventually fire.
+func quasiquoteAt(v Any, depth int) ast.Expr {
+	if c, ok := v.(commented); ok {
+		return quasiquoteAt(c.Form, depth)
+	}
+	list, ok := v.([]Any)
+	if !ok {
+		return quoteExpr(v)
+	}
+	if sym, form, ok := quoteForm(list); ok {
+		switch sym {
+		case "quasiquote":
+			return quasiquoteReform(sym, form, depth+1)
+		case "unquote":
+			if depth == 1 {
+				return toExpr(form)
+			}
+			return quasiquoteReform(sym, form, depth-1)
+		case "unquote-splice":
+			if depth == 1 {
+				panic(errUnquoteSpliceContext)
+			}
+			return quasiquoteReform(sym, form, depth-1)
+		}
+	}
+
+	acc := ast.Expr(&ast.CompositeLit{Type: interfaceSliceType()})
+	var pending []ast.Expr
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		acc = &ast.CallExpr{Fun: ast.NewIdent("append"), Args: append([]ast.Expr{acc}, pending...)}
+		pending = nil
+	}
+	for _, elem := range list {
+		if elemList, isList := elem.([]Any); isList && depth == 1 {
+			if sym, form, ok := quoteForm(elemList); ok {
+				switch sym {
+				case "unquote":
+					pending = append(pending, toExpr(form))
+					continue
+				case "unquote-splice":
+					flush()
+					// Ellipsis just needs to be non-zero to mark this call
+					// variadic so the printer renders the trailing "...".
+					acc = &ast.CallExpr{
+						Fun:      ast.NewIdent("append"),
+						Args:     []ast.Expr{acc, toExpr(form)},
+						Ellipsis: 1,
+					}
+					continue
+				}
+			}
+		}
+		pending = append(pending, quasiquoteAt(elem, depth))
+	}
+	flush()
+	return acc
+}
+
+// quasiquoteReform rebuilds a quote-family form - `(quasiquote x)`,
+// `(unquote x)` or `(unquote-splice x)` - seen at a depth where it doesn't
+// fire as the literal two-element list it still is: the symbol name as a
+// self-quoting string (matching quoteExpr's symbol handling) alongside x
+// itself, re-expanded at newDepth so a deeper unquote can still surface.
+func quasiquoteReform(sym Symbol, form Any, newDepth int) ast.Expr {
+	return &ast.CompositeLit{
+		Type: interfaceSliceType(),
+		Elts: []ast.Expr{
+			&ast.BasicLit{Kind: litKind("STRING"), Value: strconv.Quote(string(sym))},
+			quasiquoteAt(form, newDepth),
+		},
+	}
+}
+
+// interfaceSliceType is the `[]interface{}` go/ast spells out wherever a
+// quoted or quasiquoted list is lowered to a composite literal.
+func interfaceSliceType() *ast.ArrayType {
+	return &ast.ArrayType{Elt: &ast.InterfaceType{Methods: &ast.FieldList{}}}
+}
+
+// errUnquoteSpliceContext reports unquote-splice used where there's no
+// enclosing list to splice into, e.g. `` `,@x `` at the top level. It's a
+// *MacroError like the unquote/unquote-splice-outside-quasiquote panic in
+// ast.go, so reportSyntaxError prints it cleanly instead of letting it
+// crash out as an unhandled panic.
+var errUnquoteSpliceContext = &MacroError{Msg: "unquote-splice used outside a list context"}